@@ -0,0 +1,264 @@
+// Package alloytags provides shared helpers for interpreting the
+// `alloy:"..."` and `default:"..."` struct tags used to describe Alloy
+// syntax blocks and attributes in Go. It is consumed by both
+// syntax/token/builder (encoding) and syntax/vm (decoding) so the two stay
+// in agreement about what a given tag means.
+package alloytags
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind distinguishes an Alloy attribute field from an Alloy block field.
+type Kind int
+
+const (
+	KindAttr Kind = iota
+	KindBlock
+)
+
+// Tag is the parsed form of a field's `alloy:"..."` tag.
+type Tag struct {
+	Name     string
+	Kind     Kind
+	Optional bool
+}
+
+// Parse parses the `alloy:"name,kind[,optional]"` tag on field. ok is
+// false for fields with no alloy tag; these are ignored by both the
+// builder and the vm.
+func Parse(field reflect.StructField) (Tag, bool) {
+	raw, ok := field.Tag.Lookup("alloy")
+	if !ok || raw == "" {
+		return Tag{}, false
+	}
+
+	parts := strings.Split(raw, ",")
+	tag := Tag{Name: parts[0]}
+	for _, opt := range parts[1:] {
+		switch opt {
+		case "attr":
+			tag.Kind = KindAttr
+		case "block":
+			tag.Kind = KindBlock
+		case "optional":
+			tag.Optional = true
+		}
+	}
+	return tag, true
+}
+
+// SetToDefault returns the result of calling SetToDefault on a fresh zero
+// value of rt, if *rt implements it.
+//
+// *rt can implement SetToDefault by promotion, through an embedded
+// pointer field with no SetToDefault of its own. On a fresh zero value
+// that embedded pointer is nil, so calling the promoted method would
+// dereference it and panic; that's not a real default for rt, just an
+// artifact of method promotion, so it's treated the same as rt having no
+// SetToDefault at all.
+func SetToDefault(rt reflect.Type) (zero reflect.Value, ok bool) {
+	ptr := reflect.New(rt)
+	setter, hasSetter := ptr.Interface().(interface{ SetToDefault() })
+	if !hasSetter {
+		return reflect.Value{}, false
+	}
+
+	defer func() {
+		if recover() != nil {
+			zero, ok = reflect.Value{}, false
+		}
+	}()
+
+	setter.SetToDefault()
+	return ptr.Elem(), true
+}
+
+// CallSetToDefault calls SetToDefault on target, a pointer to a struct,
+// if it implements that method. It guards against the same nil-embed
+// panic described on SetToDefault above: if target only has the method
+// by promotion through a nil anonymous pointer field, the call is a
+// no-op rather than a panic, leaving target exactly as it was.
+func CallSetToDefault(target interface{}) {
+	setter, ok := target.(interface{ SetToDefault() })
+	if !ok {
+		return
+	}
+
+	defer func() { recover() }()
+	setter.SetToDefault()
+}
+
+// ParseDefaultTag converts the string form of a `default:"..."` tag into a
+// value of the given field type. Supported kinds mirror what
+// creasty/defaults-style libraries handle: ints/uints/floats/bools/
+// strings, time.Duration, and pipe-separated slices of those scalars
+// (e.g. `default:"a|b|c"`).
+func ParseDefaultTag(raw string, ft reflect.Type) (reflect.Value, error) {
+	if ft == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(d), nil
+	}
+
+	if ft.Kind() == reflect.Slice {
+		parts := strings.Split(raw, "|")
+		out := reflect.MakeSlice(ft, len(parts), len(parts))
+		for i, part := range parts {
+			elem, err := parseScalar(part, ft.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out.Index(i).Set(elem)
+		}
+		return out, nil
+	}
+
+	return parseScalar(raw, ft)
+}
+
+func parseScalar(raw string, ft reflect.Type) (reflect.Value, error) {
+	if ft == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(d), nil
+	}
+
+	switch ft.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(raw).Convert(ft), nil
+	case reflect.Bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(v).Convert(ft), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		rv := reflect.New(ft).Elem()
+		rv.SetInt(v)
+		return rv, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		rv := reflect.New(ft).Elem()
+		rv.SetUint(v)
+		return rv, nil
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		rv := reflect.New(ft).Elem()
+		rv.SetFloat(v)
+		return rv, nil
+	default:
+		return reflect.Value{}, strconv.ErrSyntax
+	}
+}
+
+// DefaultsForType returns a map from field index to that field's declared
+// default value, for every field of rt that has one. A field's default
+// comes from rt's SetToDefault method if rt implements one (taking
+// precedence over tag defaults, to preserve back-compat with blocks that
+// predate tag-based defaults); otherwise it comes from a `default:"..."`
+// tag on the field itself.
+func DefaultsForType(rt reflect.Type) map[int]reflect.Value {
+	defaults := make(map[int]reflect.Value)
+
+	if zero, ok := SetToDefault(rt); ok {
+		for i := 0; i < rt.NumField(); i++ {
+			defaults[i] = zero.Field(i)
+		}
+		return defaults
+	}
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		raw, ok := field.Tag.Lookup("default")
+		if !ok {
+			continue
+		}
+		val, err := ParseDefaultTag(raw, field.Type)
+		if err != nil {
+			continue
+		}
+		defaults[i] = val
+	}
+
+	return defaults
+}
+
+// FullDefault returns the fully-populated default value of rt: the
+// result of rt's SetToDefault method, if it has one, or otherwise a zero
+// value of rt with each field's `default:"..."` tag value set and every
+// other struct (or pointer-to-struct) block field filled in with its own
+// type's FullDefault, derived recursively; a pointer block field is
+// therefore never nil in the result. It's the building block for eliding an optional block field whose
+// containing struct has no way to declare a default for it directly
+// (no SetToDefault, no field-level tag - only the block's own type
+// does), where DefaultsForType alone falls short.
+func FullDefault(rt reflect.Type) reflect.Value {
+	if zero, ok := SetToDefault(rt); ok {
+		return zero
+	}
+
+	out := reflect.New(rt).Elem()
+	fieldDefaults := DefaultsForType(rt)
+
+	for i := 0; i < rt.NumField(); i++ {
+		if def, ok := fieldDefaults[i]; ok {
+			out.Field(i).Set(def)
+			continue
+		}
+
+		structType, isPtr, ok := BlockStructType(rt.Field(i))
+		if !ok {
+			continue
+		}
+
+		inner := FullDefault(structType)
+		if isPtr {
+			ptr := reflect.New(structType)
+			ptr.Elem().Set(inner)
+			out.Field(i).Set(ptr)
+		} else {
+			out.Field(i).Set(inner)
+		}
+	}
+
+	return out
+}
+
+// BlockStructType reports the struct type backing field, if field is a
+// `,block`-tagged field holding a struct or pointer to one, and whether
+// it's the pointer form.
+func BlockStructType(field reflect.StructField) (structType reflect.Type, isPtr bool, ok bool) {
+	tag, hasTag := Parse(field)
+	if !hasTag || tag.Kind != KindBlock {
+		return nil, false, false
+	}
+
+	ft := field.Type
+	if ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+		isPtr = true
+	}
+	if ft.Kind() != reflect.Struct {
+		return nil, false, false
+	}
+
+	return ft, isPtr, true
+}
@@ -0,0 +1,356 @@
+// Package builder exposes utilities for constructing Alloy configuration
+// text from Go values. It is the inverse of syntax/vm: where vm decodes
+// Alloy syntax into Go values, builder encodes Go values back into Alloy
+// syntax text.
+package builder
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/grafana/alloy/syntax/internal/alloytags"
+)
+
+// File represents a buildable Alloy configuration file. Use NewFile to
+// create one and Body to append content to it.
+type File struct {
+	body *Body
+}
+
+// NewFile creates a new, empty File.
+func NewFile() *File {
+	return &File{body: &Body{}}
+}
+
+// Body returns the root Body of f, to which blocks and attributes may be
+// appended.
+func (f *File) Body() *Body { return f.body }
+
+// Bytes renders f to its Alloy syntax text representation.
+func (f *File) Bytes() []byte {
+	return []byte(f.body.String())
+}
+
+// Body holds a sequence of Alloy attributes and blocks which will be
+// rendered in the order they were appended.
+type Body struct {
+	lines []string
+}
+
+// AppendFrom reflects over goValue (which must be a struct or pointer to a
+// struct decorated with `alloy:"..."` field tags) and appends the
+// corresponding attributes and blocks to b.
+//
+// Fields tagged `,optional` whose current value matches the field's
+// declared default are omitted. A field's declared default comes from,
+// in order of precedence:
+//
+//  1. The value produced by a SetToDefault method on the containing
+//     struct, if one is defined.
+//  2. The value described by a `default:"..."` struct tag on the field
+//     itself (see alloytags.ParseDefaultTag).
+func (b *Body) AppendFrom(goValue interface{}) {
+	rv := reflect.ValueOf(goValue)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("builder: AppendFrom called with non-struct value %s", rv.Kind()))
+	}
+
+	// Copy into an addressable value: the walker allocates fields behind
+	// nil anonymous pointers as it descends (see fieldByIndex), which
+	// requires Set to be callable on them.
+	addr := reflect.New(rv.Type()).Elem()
+	addr.Set(rv)
+	rv = addr
+
+	defaults := alloytags.DefaultsForType(rv.Type())
+	b.appendStruct(rv, defaults, nil)
+}
+
+// appendStruct walks the exported, alloy-tagged fields of rv and appends
+// their rendered form to b. defaults holds the precomputed default value
+// for each field index of rv's type, keyed the same way reflect.Type.Field
+// indexes fields.
+//
+// Anonymous fields with no alloy tag of their own are a common
+// composition pattern: their alloy-tagged fields are promoted into this
+// same Body as if they were declared directly on rv's type. A nil
+// anonymous pointer whose declared default is non-nil is elided outright
+// (it means "use the default"); otherwise it's allocated before
+// descending so its default-annotated fields still participate in
+// "matches default -> omit".
+func (b *Body) appendStruct(rv reflect.Value, defaults map[int]reflect.Value, path []string) {
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag, hasTag := alloytags.Parse(field)
+
+		if field.Anonymous && !hasTag {
+			rawFv := rv.Field(i)
+			if rawFv.Kind() == reflect.Ptr && rawFv.IsNil() {
+				if def := defaults[i]; def.IsValid() && def.Kind() == reflect.Ptr && !def.IsNil() {
+					// A nil embed whose declared default is non-nil means
+					// "use the default": elide it entirely rather than
+					// diffing a freshly-allocated zero value against the
+					// embedded type's own (possibly different) default.
+					continue
+				}
+			}
+
+			fv, ok := fieldByIndex(rv, i)
+			if !ok {
+				continue
+			}
+			for fv.Kind() == reflect.Ptr {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				b.appendStruct(fv, alloytags.DefaultsForType(fv.Type()), path)
+			}
+			continue
+		}
+
+		if !hasTag {
+			continue
+		}
+
+		fv, ok := fieldByIndex(rv, i)
+		if !ok {
+			continue
+		}
+
+		if tag.Optional {
+			if isDefaultValue(fv, defaults[i]) {
+				continue
+			}
+			// defaults[i] only covers a default the containing struct
+			// declares for this field directly (via SetToDefault or a
+			// `default:"..."` tag). A block field can also be all-default
+			// purely because its own type carries defaults that the
+			// containing struct never mentions - fall back to that.
+			if !defaults[i].IsValid() && tag.Kind == alloytags.KindBlock && fv.Kind() != reflect.Slice && fv.Kind() != reflect.Map && isBlockDefaultValue(fv) {
+				continue
+			}
+		}
+
+		switch {
+		case tag.Kind == alloytags.KindAttr:
+			b.lines = append(b.lines, fmt.Sprintf("%s = %s", tag.Name, renderAttr(fv)))
+		case tag.Kind == alloytags.KindBlock && fv.Kind() == reflect.Slice:
+			b.appendBlockSlice(tag.Name, fv, append(path, tag.Name))
+		case tag.Kind == alloytags.KindBlock && fv.Kind() == reflect.Map:
+			b.appendBlockMap(tag.Name, fv, append(path, tag.Name))
+		case tag.Kind == alloytags.KindBlock:
+			b.appendBlock(tag.Name, fv, append(path, tag.Name))
+		}
+	}
+}
+
+// fieldByIndex returns the value of rv's field i. If that field is a nil
+// anonymous pointer, it is allocated first so traversal (and default
+// comparisons) can proceed on the pointee rather than panicking on a nil
+// dereference; ok is false if allocation isn't possible (the pointee
+// isn't a struct) or the field isn't settable.
+func fieldByIndex(rv reflect.Value, i int) (reflect.Value, bool) {
+	field := rv.Type().Field(i)
+	fv := rv.Field(i)
+
+	if field.Anonymous && fv.Kind() == reflect.Ptr && fv.IsNil() {
+		if fv.Type().Elem().Kind() != reflect.Struct || !fv.CanSet() {
+			return reflect.Value{}, false
+		}
+		fv.Set(reflect.New(fv.Type().Elem()))
+	}
+
+	return fv, true
+}
+
+func (b *Body) appendBlock(name string, rv reflect.Value, path []string) {
+	inner := &Body{}
+
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			// A nil block pointer with no declared default renders as an
+			// empty block header; callers relying on defaults should
+			// already have elided this via isDefaultValue.
+			b.lines = append(b.lines, fmt.Sprintf("%s { }", name))
+			return
+		}
+		rv = rv.Elem()
+	}
+
+	inner.appendStruct(rv, alloytags.DefaultsForType(rv.Type()), path)
+
+	if len(inner.lines) == 0 {
+		b.lines = append(b.lines, fmt.Sprintf("%s { }", name))
+		return
+	}
+
+	b.lines = append(b.lines, fmt.Sprintf("%s {", name))
+	for _, line := range inner.lines {
+		b.lines = append(b.lines, "\t"+line)
+	}
+	b.lines = append(b.lines, "}")
+}
+
+// appendBlockSlice renders a repeated block (a `,block,optional` field
+// whose type is a slice of structs or struct pointers), one block
+// statement per element. Each element is diffed against a fresh
+// SetToDefault()-initialized instance of the element type; a run of
+// trailing elements that all match that default is truncated entirely
+// rather than rendered as empty block placeholders.
+func (b *Body) appendBlockSlice(name string, rv reflect.Value, path []string) {
+	structType, isPtr := elementStructType(rv.Type())
+
+	type renderedElem struct {
+		lines     []string
+		isDefault bool
+	}
+
+	elems := make([]renderedElem, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		target, isDefault := structForElement(rv.Index(i), structType, isPtr)
+
+		inner := &Body{}
+		inner.appendStruct(target, alloytags.DefaultsForType(structType), path)
+		elems[i] = renderedElem{lines: inner.lines, isDefault: isDefault}
+	}
+
+	end := len(elems)
+	for end > 0 && elems[end-1].isDefault {
+		end--
+	}
+
+	for i := 0; i < end; i++ {
+		b.appendBlockLines(name, elems[i].lines)
+	}
+}
+
+// appendBlockMap renders a block field keyed by map (a `,block,optional`
+// field whose type is a map of structs or struct pointers), one labeled
+// block statement per entry, in key order. Entries whose value deep-
+// equals a fresh SetToDefault()-initialized instance of the element type
+// are omitted; an entirely-default map is elided altogether.
+func (b *Body) appendBlockMap(name string, rv reflect.Value, path []string) {
+	structType, isPtr := elementStructType(rv.Type())
+
+	keys := rv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+	for _, k := range keys {
+		elemVal := rv.MapIndex(k)
+		if isPtr && elemVal.IsNil() {
+			continue
+		}
+
+		target, isDefault := structForElement(elemVal, structType, isPtr)
+		if isDefault {
+			continue
+		}
+
+		inner := &Body{}
+		inner.appendStruct(target, alloytags.DefaultsForType(structType), path)
+		b.appendBlockLines(fmt.Sprintf("%s %q", name, k.String()), inner.lines)
+	}
+}
+
+// appendBlockLines appends a single block statement with the given
+// header (e.g. "foo" or `foo "key"`) and body lines, collapsing to an
+// empty placeholder when there are no lines to render.
+func (b *Body) appendBlockLines(header string, lines []string) {
+	if len(lines) == 0 {
+		b.lines = append(b.lines, fmt.Sprintf("%s { }", header))
+		return
+	}
+
+	b.lines = append(b.lines, fmt.Sprintf("%s {", header))
+	for _, line := range lines {
+		b.lines = append(b.lines, "\t"+line)
+	}
+	b.lines = append(b.lines, "}")
+}
+
+// elementStructType returns the struct type that sliceOrMapType's
+// elements hold, and whether elements are pointers to it.
+func elementStructType(sliceOrMapType reflect.Type) (structType reflect.Type, isPtr bool) {
+	elemType := sliceOrMapType.Elem()
+	if elemType.Kind() == reflect.Ptr {
+		return elemType.Elem(), true
+	}
+	return elemType, false
+}
+
+// structForElement returns the struct value for a slice/map element
+// (not addressable for a map of non-pointer structs), allocating a fresh
+// zero instance in place of a nil pointer,
+// and reports whether the element matches the element type's
+// SetToDefault-initialized default.
+func structForElement(elem reflect.Value, structType reflect.Type, isPtr bool) (reflect.Value, bool) {
+	target := elem
+	isDefault := false
+
+	if isPtr {
+		if elem.IsNil() {
+			target = reflect.New(structType).Elem()
+			isDefault = true
+		} else {
+			target = elem.Elem()
+		}
+	}
+
+	if def, ok := alloytags.SetToDefault(structType); ok && reflect.DeepEqual(target.Interface(), def.Interface()) {
+		isDefault = true
+	}
+
+	return target, isDefault
+}
+
+func (b *Body) String() string {
+	return strings.Join(b.lines, "\n")
+}
+
+func renderAttr(rv reflect.Value) string {
+	return fmt.Sprintf("%v", rv.Interface())
+}
+
+// isDefaultValue reports whether fv equals its declared default, if any.
+// A nil optional pointer whose declared default is non-nil is treated as
+// matching it: decoding an absent field already fills in that same
+// default (see vm.applyTagDefaults and the SetToDefault-on-decode path),
+// so nil and "the default" are interchangeable on the way out too.
+func isDefaultValue(fv reflect.Value, def reflect.Value) bool {
+	if !def.IsValid() {
+		return false
+	}
+	if fv.Kind() == reflect.Ptr && fv.IsNil() {
+		return def.Kind() == reflect.Ptr && !def.IsNil()
+	}
+	return reflect.DeepEqual(fv.Interface(), def.Interface())
+}
+
+// isBlockDefaultValue reports whether fv, an optional block field with
+// no default declared on its containing struct, matches its own
+// element type's recursively-derived default (alloytags.FullDefault). A
+// nil pointer never matches, so it is rendered (as an empty block) rather
+// than elided.
+func isBlockDefaultValue(fv reflect.Value) bool {
+	target := fv
+	for target.Kind() == reflect.Ptr {
+		if target.IsNil() {
+			return false
+		}
+		target = target.Elem()
+	}
+	if target.Kind() != reflect.Struct {
+		return false
+	}
+	return reflect.DeepEqual(target.Interface(), alloytags.FullDefault(target.Type()).Interface())
+}
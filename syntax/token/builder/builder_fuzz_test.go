@@ -0,0 +1,99 @@
+package builder_test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/grafana/alloy/syntax/token/builder"
+	"github.com/grafana/alloy/syntax/vm"
+	"github.com/stretchr/testify/require"
+)
+
+// fuzzSchema describes a Go type the fuzzer should exercise: a name used
+// in failure messages, and a generator that produces a random value of
+// that type from r.
+type fuzzSchema struct {
+	name string
+	gen  func(r *rand.Rand) interface{}
+}
+
+// fuzzSchemas holds every type registered for FuzzBuilderVMRoundTrip.
+// Register additional types with registerFuzzSchema from an init func;
+// the fuzzer itself never needs editing to pick them up.
+var fuzzSchemas []fuzzSchema
+
+func registerFuzzSchema(name string, gen func(r *rand.Rand) interface{}) {
+	fuzzSchemas = append(fuzzSchemas, fuzzSchema{name: name, gen: gen})
+}
+
+func init() {
+	registerFuzzSchema("AttrWithDefault", func(r *rand.Rand) interface{} {
+		return AttrWithDefault{Number: r.Int()}
+	})
+	registerFuzzSchema("AttrWithTagDefault", func(r *rand.Rand) interface{} {
+		return AttrWithTagDefault{Number: r.Int()}
+	})
+	registerFuzzSchema("StructPropagatingDefault", func(r *rand.Rand) interface{} {
+		return StructPropagatingDefault{Inner: AttrWithDefault{Number: r.Int()}}
+	})
+	registerFuzzSchema("PtrPropagatingDefault", func(r *rand.Rand) interface{} {
+		// A nil Inner is deliberately lossy on round trip (it encodes as
+		// the default and decodes back non-nil): TestPtrPropagatingDefaultWithNil
+		// covers that case explicitly, so the fuzzer only generates
+		// non-nil values here.
+		return PtrPropagatingDefault{Inner: &AttrWithDefault{Number: r.Int()}}
+	})
+	registerFuzzSchema("MismatchingDefault", func(r *rand.Rand) interface{} {
+		// Same deliberate-lossiness caveat as PtrPropagatingDefault above.
+		return MismatchingDefault{Inner: &AttrWithDefault{Number: r.Int()}}
+	})
+	registerFuzzSchema("EmbeddedStructPropagatingDefault", func(r *rand.Rand) interface{} {
+		// Same deliberate-lossiness caveat as PtrPropagatingDefault above,
+		// covered by TestEmbeddedStructPropagatingDefaultWithNil.
+		return EmbeddedStructPropagatingDefault{AttrWithDefault: &AttrWithDefault{Number: r.Int()}}
+	})
+	registerFuzzSchema("EmbeddedPtrPropagatingDefault", func(r *rand.Rand) interface{} {
+		// Same deliberate-lossiness caveat, covered by
+		// TestEmbeddedPtrPropagatingDefaultWithNil.
+		return EmbeddedPtrPropagatingDefault{AttrWithDefault: &AttrWithDefault{Number: r.Int()}}
+	})
+}
+
+// FuzzBuilderVMRoundTrip checks, for every registered schema, that
+// decoding an encoded value always reproduces the original value
+// (Decode(Encode(v)) == v), and that re-encoding the decoded value is
+// byte-identical to the first encoding (idempotence). The latter catches
+// default-elision bugs the table-driven TestNestedDefaults cases miss,
+// since it's exercised against arbitrary generated inputs rather than a
+// fixed set of hand-picked ones.
+func FuzzBuilderVMRoundTrip(f *testing.F) {
+	for seed := int64(0); seed < 32; seed++ {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		schema := fuzzSchemas[int(uint64(seed)%uint64(len(fuzzSchemas)))]
+		input := schema.gen(rand.New(rand.NewSource(seed)))
+
+		file := builder.NewFile()
+		file.Body().AppendFrom(input)
+		encoded := file.Bytes()
+
+		decoded := decodeFuzzValue(t, encoded, input)
+		require.Equal(t, input, decoded, "%s: Decode(Encode(v)) didn't reproduce v", schema.name)
+
+		file2 := builder.NewFile()
+		file2.Body().AppendFrom(decoded)
+		require.Equal(t, encoded, file2.Bytes(), "%s: re-encoding the decoded value changed the output", schema.name)
+	})
+}
+
+func decodeFuzzValue(t *testing.T, encoded []byte, input interface{}) interface{} {
+	t.Helper()
+
+	block := parseBlock(t, string(encoded))
+	vPtr := reflect.New(reflect.TypeOf(input)).Interface()
+	require.NoError(t, vm.New(block).Evaluate(nil, vPtr), "alloy evaluation error")
+	return reflect.ValueOf(vPtr).Elem().Interface()
+}
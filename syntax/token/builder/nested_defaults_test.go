@@ -116,7 +116,84 @@ var testCases = []struct {
 		expectedAlloy: `
 		inner {
 			number = 42
-		}	
+		}
+		`,
+	},
+	{
+		name:          "tag default - input matching default",
+		input:         StructPropagatingTagDefault{Inner: AttrWithTagDefault{Number: defaultNumber}},
+		expectedAlloy: "",
+	},
+	{
+		name:  "tag default - input with zero-value struct",
+		input: StructPropagatingTagDefault{},
+		expectedAlloy: `
+		inner {
+			number = 0
+		}
+		`,
+	},
+	{
+		name:  "tag default - input with non-default value",
+		input: StructPropagatingTagDefault{Inner: AttrWithTagDefault{Number: 42}},
+		expectedAlloy: `
+		inner {
+			number = 42
+		}
+		`,
+	},
+	{
+		name:          "embedded struct propagating default - input matching default",
+		input:         EmbeddedStructPropagatingDefault{AttrWithDefault: &AttrWithDefault{Number: defaultNumber}},
+		expectedAlloy: "",
+	},
+	{
+		name:          "embedded struct propagating default - input with non-default value",
+		input:         EmbeddedStructPropagatingDefault{AttrWithDefault: &AttrWithDefault{Number: 42}},
+		expectedAlloy: "number = 42",
+	},
+	{
+		name:          "embedded pointer propagating default - input with non-default value",
+		input:         EmbeddedPtrPropagatingDefault{AttrWithDefault: &AttrWithDefault{Number: 42}},
+		expectedAlloy: "number = 42",
+	},
+	{
+		name:          "slice of blocks - nil slice",
+		input:         SliceOfBlocksDefault{},
+		expectedAlloy: "",
+	},
+	{
+		name:  "slice of blocks - no default elements",
+		input: SliceOfBlocksDefault{Items: []*AttrWithDefault{{Number: 1}, {Number: 2}}},
+		expectedAlloy: `
+		item {
+			number = 1
+		}
+		item {
+			number = 2
+		}
+		`,
+	},
+	{
+		name:  "slice of blocks - default element not in tail position is still rendered",
+		input: SliceOfBlocksDefault{Items: []*AttrWithDefault{{Number: defaultNumber}, {Number: 2}}},
+		expectedAlloy: `
+		item { }
+		item {
+			number = 2
+		}
+		`,
+	},
+	{
+		name:  "map of blocks - no default entries, in key order",
+		input: MapOfBlocksDefault{Items: map[string]*AttrWithDefault{"a": {Number: 1}, "b": {Number: 2}}},
+		expectedAlloy: `
+		item "a" {
+			number = 1
+		}
+		item "b" {
+			number = 2
+		}
 		`,
 	},
 }
@@ -164,6 +241,110 @@ func TestPtrPropagatingDefaultWithNil(t *testing.T) {
 	require.Equal(t, expectedDecoded, actualOut)
 }
 
+func TestEmbeddedStructPropagatingDefaultWithNil(t *testing.T) {
+	// Same special case as TestPtrPropagatingDefaultWithNil, but the
+	// default-carrying struct is reached through a nil anonymous pointer
+	// embed rather than a named block field.
+	input := EmbeddedStructPropagatingDefault{}
+	expectedEncodedAlloy := ""
+	expectedDecoded := EmbeddedStructPropagatingDefault{AttrWithDefault: &AttrWithDefault{Number: 123}}
+
+	f := builder.NewFile()
+	f.Body().AppendFrom(input)
+	actualAlloy := string(f.Bytes())
+	expected := format(t, expectedEncodedAlloy)
+	require.Equal(t, expected, actualAlloy, "generated Alloy didn't match expected")
+
+	eval := vm.New(parseBlock(t, actualAlloy))
+	vPtr := reflect.New(reflect.TypeOf(input)).Interface()
+	require.NoError(t, eval.Evaluate(nil, vPtr), "alloy evaluation error")
+
+	actualOut := reflect.ValueOf(vPtr).Elem().Interface()
+	require.Equal(t, expectedDecoded, actualOut)
+}
+
+func TestEmbeddedPtrPropagatingDefaultWithNil(t *testing.T) {
+	// EmbeddedPtrPropagatingDefault has no SetToDefault of its own, so
+	// encoding allocates the nil embedded pointer as a zero AttrWithDefault;
+	// its Number of 0 differs from AttrWithDefault's own default and is
+	// emitted, and decoding yields a non-nil embed holding 0.
+	input := EmbeddedPtrPropagatingDefault{}
+	expectedEncodedAlloy := "number = 0"
+	expectedDecoded := EmbeddedPtrPropagatingDefault{AttrWithDefault: &AttrWithDefault{Number: 0}}
+
+	f := builder.NewFile()
+	f.Body().AppendFrom(input)
+	actualAlloy := string(f.Bytes())
+	expected := format(t, expectedEncodedAlloy)
+	require.Equal(t, expected, actualAlloy, "generated Alloy didn't match expected")
+
+	eval := vm.New(parseBlock(t, actualAlloy))
+	vPtr := reflect.New(reflect.TypeOf(input)).Interface()
+	require.NoError(t, eval.Evaluate(nil, vPtr), "alloy evaluation error")
+
+	actualOut := reflect.ValueOf(vPtr).Elem().Interface()
+	require.Equal(t, expectedDecoded, actualOut)
+}
+
+func TestSliceOfBlocksDefault_TrailingDefaultsTruncated(t *testing.T) {
+	// A run of trailing default elements is dropped entirely rather than
+	// rendered as empty placeholders, so decoding produces a shorter
+	// slice than the input - the encode/decode round trip is intentionally
+	// lossy here (as in the nil-pointer and map-entry cases), which is why
+	// it's asserted here instead of in the TestNestedDefaults table.
+	input := SliceOfBlocksDefault{
+		Items: []*AttrWithDefault{{Number: 1}, {Number: defaultNumber}, {Number: defaultNumber}},
+	}
+	expectedEncodedAlloy := `
+	item {
+		number = 1
+	}
+	`
+	expectedDecoded := SliceOfBlocksDefault{Items: []*AttrWithDefault{{Number: 1}}}
+
+	f := builder.NewFile()
+	f.Body().AppendFrom(input)
+	actualAlloy := string(f.Bytes())
+	expected := format(t, expectedEncodedAlloy)
+	require.Equal(t, expected, actualAlloy, "generated Alloy didn't match expected")
+
+	eval := vm.New(parseBlock(t, actualAlloy))
+	vPtr := reflect.New(reflect.TypeOf(input)).Interface()
+	require.NoError(t, eval.Evaluate(nil, vPtr), "alloy evaluation error")
+
+	actualOut := reflect.ValueOf(vPtr).Elem().Interface()
+	require.Equal(t, expectedDecoded, actualOut)
+}
+
+func TestMapOfBlocksDefault_DefaultEntryElided(t *testing.T) {
+	// A map entry whose value matches the element default is omitted
+	// from the encoded output entirely, so - like the slice tail-
+	// truncation case above - decoding won't reproduce that key.
+	input := MapOfBlocksDefault{Items: map[string]*AttrWithDefault{
+		"a": {Number: defaultNumber},
+		"b": {Number: 42},
+	}}
+	expectedEncodedAlloy := `
+	item "b" {
+		number = 42
+	}
+	`
+	expectedDecoded := MapOfBlocksDefault{Items: map[string]*AttrWithDefault{"b": {Number: 42}}}
+
+	f := builder.NewFile()
+	f.Body().AppendFrom(input)
+	actualAlloy := string(f.Bytes())
+	expected := format(t, expectedEncodedAlloy)
+	require.Equal(t, expected, actualAlloy, "generated Alloy didn't match expected")
+
+	eval := vm.New(parseBlock(t, actualAlloy))
+	vPtr := reflect.New(reflect.TypeOf(input)).Interface()
+	require.NoError(t, eval.Evaluate(nil, vPtr), "alloy evaluation error")
+
+	actualOut := reflect.ValueOf(vPtr).Elem().Interface()
+	require.Equal(t, expectedDecoded, actualOut)
+}
+
 // StructPropagatingDefault has the outer defaults matching the inner block's defaults. The inner block is a struct.
 type StructPropagatingDefault struct {
 	Inner AttrWithDefault `alloy:"inner,block,optional"`
@@ -220,6 +401,54 @@ func (i *AttrWithDefault) SetToDefault() {
 	*i = AttrWithDefault{Number: defaultNumber}
 }
 
+// StructPropagatingTagDefault is the tag-based-default analogue of
+// StructPropagatingDefault: it has no SetToDefault method, relying purely
+// on the `default:"..."` tag declared on AttrWithTagDefault.Number.
+type StructPropagatingTagDefault struct {
+	Inner AttrWithTagDefault `alloy:"inner,block,optional"`
+}
+
+// AttrWithTagDefault has a declarative default value of a non-zero
+// number, supplied via the `default` struct tag rather than a
+// SetToDefault method.
+type AttrWithTagDefault struct {
+	Number int `alloy:"number,attr,optional" default:"123"`
+}
+
+// EmbeddedStructPropagatingDefault is the embedded-field analogue of
+// StructPropagatingDefault: AttrWithDefault is composed via an anonymous
+// pointer embed (a common composition pattern for shared argument
+// groups) instead of a named "inner" block, exercising default
+// propagation through a nil embedded pointer.
+type EmbeddedStructPropagatingDefault struct {
+	*AttrWithDefault
+}
+
+func (o *EmbeddedStructPropagatingDefault) SetToDefault() {
+	inner := &AttrWithDefault{}
+	inner.SetToDefault()
+	*o = EmbeddedStructPropagatingDefault{AttrWithDefault: inner}
+}
+
+// EmbeddedPtrPropagatingDefault has no SetToDefault of its own; its only
+// default comes from descending into the nil embedded pointer and
+// consulting AttrWithDefault's own SetToDefault.
+type EmbeddedPtrPropagatingDefault struct {
+	*AttrWithDefault
+}
+
+// SliceOfBlocksDefault exercises default-diffing and tail-truncation for
+// a repeated (`,block,optional`) slice field.
+type SliceOfBlocksDefault struct {
+	Items []*AttrWithDefault `alloy:"item,block,optional"`
+}
+
+// MapOfBlocksDefault exercises default-diffing for a `,block,optional`
+// field keyed by map.
+type MapOfBlocksDefault struct {
+	Items map[string]*AttrWithDefault `alloy:"item,block,optional"`
+}
+
 func parseBlock(t *testing.T, input string) *ast.BlockStmt {
 	t.Helper()
 
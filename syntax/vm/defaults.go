@@ -0,0 +1,63 @@
+package vm
+
+import (
+	"reflect"
+
+	"github.com/grafana/alloy/syntax/internal/alloytags"
+)
+
+// applyTagDefaults fills any zero-valued field of rv that was not
+// explicitly set from the AST (tracked in set) and has a declared default
+// with that default
+// value. Fields populated via a SetToDefault call already carry their
+// default, so this only needs to cover fields whose default comes from a
+// `default:"..."` struct tag - plus, for a block field with no default
+// declared on rv's own type, one derived recursively from the block's
+// own type (see applyBlockDefault).
+func applyTagDefaults(rv reflect.Value, set map[int]bool) {
+	rt := rv.Type()
+	defaults := alloytags.DefaultsForType(rt)
+
+	for idx, def := range defaults {
+		if set[idx] {
+			continue
+		}
+		field := rv.Field(idx)
+		if !field.IsZero() {
+			continue
+		}
+		field.Set(def)
+	}
+
+	for i := 0; i < rt.NumField(); i++ {
+		if set[i] {
+			continue
+		}
+		if _, ok := defaults[i]; ok {
+			continue
+		}
+		applyBlockDefault(rt.Field(i), rv.Field(i))
+	}
+}
+
+// applyBlockDefault fills field, an optional block field left at its
+// zero value with no default declared on its containing struct, from
+// its own element type's recursively-derived default
+// (alloytags.FullDefault). This covers a block composed purely of
+// tag-based defaults, which the containing struct has no way to
+// describe directly the way a `default:"..."` tag or SetToDefault does.
+func applyBlockDefault(sf reflect.StructField, field reflect.Value) {
+	structType, isPtr, ok := alloytags.BlockStructType(sf)
+	if !ok || !field.IsZero() {
+		return
+	}
+
+	def := alloytags.FullDefault(structType)
+	if isPtr {
+		ptr := reflect.New(structType)
+		ptr.Elem().Set(def)
+		field.Set(ptr)
+	} else {
+		field.Set(def)
+	}
+}
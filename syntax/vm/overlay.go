@@ -0,0 +1,153 @@
+package vm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/grafana/alloy/syntax/internal/alloytags"
+)
+
+// Option configures a VM constructed with New.
+type Option func(*VM)
+
+// WithOverlay attaches an environment-variable overlay to the VM. For
+// every optional attribute of the top-level body and of each block present
+// in the parsed AST, the overlay derives a key
+// from prefix and the attribute's dotted block path (e.g. "ALLOY_" +
+// "INNER_NUMBER" for the "number" attribute of an "inner" block under
+// prefix "ALLOY_") and, if env contains a matching "KEY=value" entry,
+// converts that value to the attribute's declared type and uses it in
+// place of both the parsed AST value and the field's default. Attributes
+// of a block that the AST omits are not overlaid.
+//
+// Map-typed attributes support decomposition: PREFIX_FOO_KEY=value
+// overlays the "key" entry of the "foo" map attribute, matching the
+// pattern popularized by traefik's env decoder.
+//
+// This lets the same Alloy configuration file be deployed across
+// environments while overriding a handful of knobs, without templating.
+func WithOverlay(prefix string, env []string) Option {
+	return func(vm *VM) {
+		vm.overlay = newOverlay(prefix, env)
+	}
+}
+
+// overlay holds environment-variable overrides parsed from a flat
+// PREFIX_PATH_NAME=value environment, keyed by the upper-cased
+// "PREFIX_PATH_NAME" form of the block path + attribute name they
+// override.
+type overlay struct {
+	prefix string
+	env    map[string]string
+}
+
+func newOverlay(prefix string, env []string) *overlay {
+	o := &overlay{prefix: strings.ToUpper(prefix), env: make(map[string]string, len(env))}
+	for _, kv := range env {
+		key, val, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		o.env[strings.ToUpper(key)] = val
+	}
+	return o
+}
+
+func (o *overlay) envKey(path []string, name string) string {
+	parts := append(append([]string{}, path...), name)
+	return o.prefix + strings.ToUpper(strings.Join(parts, "_"))
+}
+
+// lookup returns the raw override for the attribute named name under the
+// given dotted block path, and whether one was present.
+func (o *overlay) lookup(path []string, name string) (string, bool) {
+	if o == nil {
+		return "", false
+	}
+	v, ok := o.env[o.envKey(path, name)]
+	return v, ok
+}
+
+// lookupMap returns the map entries contributed by the overlay for the
+// map-typed attribute named name under path, keyed by the lower-cased map
+// key encoded in the environment variable name.
+func (o *overlay) lookupMap(path []string, name string) map[string]string {
+	if o == nil {
+		return nil
+	}
+
+	prefix := o.envKey(path, name) + "_"
+	var out map[string]string
+	for k, v := range o.env {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]string)
+		}
+		out[strings.ToLower(strings.TrimPrefix(k, prefix))] = v
+	}
+	return out
+}
+
+// applyOverlay overwrites rv's optional attribute fields with any
+// matching overlay overrides, taking priority over both the AST value
+// already decoded into rv and the field's declared default. Fields an
+// override is applied to are marked in set so the defaults pass in
+// decodeBody/Evaluate doesn't also try to populate them.
+func (vm *VM) applyOverlay(path []string, rv reflect.Value, set map[int]bool) error {
+	if vm.overlay == nil {
+		return nil
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag, ok := alloytags.Parse(field)
+		if !ok || tag.Kind != alloytags.KindAttr || !tag.Optional {
+			continue
+		}
+
+		fv := rv.Field(i)
+
+		if fv.Kind() == reflect.Map {
+			entries := vm.overlay.lookupMap(path, tag.Name)
+			if len(entries) == 0 {
+				continue
+			}
+			if fv.IsNil() {
+				fv.Set(reflect.MakeMap(fv.Type()))
+			}
+			for k, raw := range entries {
+				val, err := overlayScalar(raw, fv.Type().Elem())
+				if err != nil {
+					return fmt.Errorf("vm: overlay for %q: %w", tag.Name, err)
+				}
+				fv.SetMapIndex(reflect.ValueOf(k), val)
+			}
+			set[i] = true
+			continue
+		}
+
+		raw, ok := vm.overlay.lookup(path, tag.Name)
+		if !ok {
+			continue
+		}
+		val, err := overlayScalar(raw, fv.Type())
+		if err != nil {
+			return fmt.Errorf("vm: overlay for %q: %w", tag.Name, err)
+		}
+		fv.Set(val)
+		set[i] = true
+	}
+
+	return nil
+}
+
+// overlayScalar converts the string form of an overlay override into a
+// value of the given field type, reusing the same scalar conversions as
+// `default:"..."` tags.
+func overlayScalar(raw string, ft reflect.Type) (reflect.Value, error) {
+	return alloytags.ParseDefaultTag(raw, ft)
+}
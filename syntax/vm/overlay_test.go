@@ -0,0 +1,72 @@
+package vm_test
+
+import (
+	"testing"
+
+	"github.com/grafana/alloy/syntax/ast"
+	"github.com/grafana/alloy/syntax/parser"
+	"github.com/grafana/alloy/syntax/vm"
+	"github.com/stretchr/testify/require"
+)
+
+type overlayInner struct {
+	Number int `alloy:"number,attr,optional"`
+}
+
+type overlayTarget struct {
+	Inner overlayInner      `alloy:"inner,block,optional"`
+	Tags  map[string]string `alloy:"tags,attr,optional"`
+}
+
+func parseOverlayBlock(t *testing.T, input string) *ast.BlockStmt {
+	t.Helper()
+
+	res, err := parser.ParseFile("", []byte("test { "+input+" }"))
+	require.NoError(t, err)
+	require.Len(t, res.Body, 1)
+
+	stmt, ok := res.Body[0].(*ast.BlockStmt)
+	require.True(t, ok, "expected stmt to be a ast.BlockStmt, got %T", res.Body[0])
+	return stmt
+}
+
+func TestWithOverlay_OverridesAttribute(t *testing.T) {
+	block := parseOverlayBlock(t, `
+		inner {
+			number = 1
+		}
+	`)
+
+	eval := vm.New(block, vm.WithOverlay("ALLOY_", []string{"ALLOY_INNER_NUMBER=42"}))
+
+	var target overlayTarget
+	require.NoError(t, eval.Evaluate(nil, &target))
+	require.Equal(t, 42, target.Inner.Number)
+}
+
+func TestWithOverlay_DecomposesMap(t *testing.T) {
+	block := parseOverlayBlock(t, ``)
+
+	eval := vm.New(block, vm.WithOverlay("ALLOY_", []string{
+		"ALLOY_TAGS_ENV=prod",
+		"ALLOY_TAGS_TEAM=obs",
+	}))
+
+	var target overlayTarget
+	require.NoError(t, eval.Evaluate(nil, &target))
+	require.Equal(t, map[string]string{"env": "prod", "team": "obs"}, target.Tags)
+}
+
+func TestWithOverlay_NoMatchLeavesASTValue(t *testing.T) {
+	block := parseOverlayBlock(t, `
+		inner {
+			number = 7
+		}
+	`)
+
+	eval := vm.New(block, vm.WithOverlay("ALLOY_", []string{"ALLOY_OTHER_NUMBER=42"}))
+
+	var target overlayTarget
+	require.NoError(t, eval.Evaluate(nil, &target))
+	require.Equal(t, 7, target.Inner.Number)
+}
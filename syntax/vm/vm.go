@@ -0,0 +1,247 @@
+// Package vm evaluates parsed Alloy syntax ASTs into Go values.
+package vm
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/grafana/alloy/syntax/ast"
+	"github.com/grafana/alloy/syntax/internal/alloytags"
+)
+
+// Scope holds the set of identifiers available while evaluating an
+// expression (variables injected by the caller, exported values from
+// other components, and so on).
+type Scope struct {
+	Variables map[string]interface{}
+}
+
+// VM evaluates a single parsed Alloy block into a Go value.
+type VM struct {
+	block   *ast.BlockStmt
+	overlay *overlay
+}
+
+// New creates a VM which evaluates block, applying any options (such as
+// WithOverlay) passed to configure it.
+func New(block *ast.BlockStmt, opts ...Option) *VM {
+	vm := &VM{block: block}
+	for _, opt := range opts {
+		opt(vm)
+	}
+	return vm
+}
+
+// Evaluate decodes vm's block into target, which must be a pointer to a
+// struct decorated with `alloy:"..."` field tags. Fields which are left
+// unset by the AST (because the corresponding attribute or block was
+// omitted) are populated from the field's declared default, if any: first
+// based on a SetToDefault method defined on the target struct, then based
+// on a `default:"..."` struct tag on the field itself.
+func (vm *VM) Evaluate(scope *Scope, target interface{}) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("vm: Evaluate requires a non-nil pointer, got %s", rv.Kind())
+	}
+	rv = rv.Elem()
+
+	alloytags.CallSetToDefault(target)
+
+	set, err := vm.decodeBody(scope, vm.block.Body, rv, nil)
+	if err != nil {
+		return err
+	}
+
+	applyTagDefaults(rv, set)
+	return nil
+}
+
+// decodeBody evaluates the attribute and block statements in body into
+// rv's fields, returning the set of field indexes that were explicitly
+// populated from the AST or the overlay (as opposed to left at their
+// zero value). path is the dotted block path leading to rv, used to
+// derive overlay environment-variable keys.
+func (vm *VM) decodeBody(scope *Scope, body ast.Body, rv reflect.Value, path []string) (map[int]bool, error) {
+	set := make(map[int]bool)
+
+	for _, stmt := range body {
+		switch stmt := stmt.(type) {
+		case *ast.AttributeStmt:
+			fv, field, topIdx, ok := resolveField(rv, stmt.Name.Name, false)
+			if !ok {
+				return nil, fmt.Errorf("vm: unrecognized attribute %q", stmt.Name.Name)
+			}
+
+			val, err := vm.evalExpr(scope, stmt.Value, field.Type)
+			if err != nil {
+				return nil, fmt.Errorf("vm: evaluating %q: %w", stmt.Name.Name, err)
+			}
+			fv.Set(val)
+			set[topIdx] = true
+
+		case *ast.BlockStmt:
+			fv, _, topIdx, ok := resolveField(rv, stmt.Name, true)
+			if !ok {
+				return nil, fmt.Errorf("vm: unrecognized block %q", stmt.Name)
+			}
+
+			innerPath := append(append([]string{}, path...), stmt.Name)
+
+			switch fv.Kind() {
+			case reflect.Slice:
+				elem, err := vm.decodeBlockElement(scope, stmt, fv.Type().Elem(), innerPath)
+				if err != nil {
+					return nil, err
+				}
+				fv.Set(reflect.Append(fv, elem))
+
+			case reflect.Map:
+				elem, err := vm.decodeBlockElement(scope, stmt, fv.Type().Elem(), innerPath)
+				if err != nil {
+					return nil, err
+				}
+				if fv.IsNil() {
+					fv.Set(reflect.MakeMap(fv.Type()))
+				}
+				fv.SetMapIndex(reflect.ValueOf(stmt.Label).Convert(fv.Type().Key()), elem)
+
+			default:
+				target := fv
+				if fv.Kind() == reflect.Ptr {
+					if fv.IsNil() {
+						fv.Set(reflect.New(fv.Type().Elem()))
+					}
+					target = fv.Elem()
+				}
+				alloytags.CallSetToDefault(target.Addr().Interface())
+
+				innerSet, err := vm.decodeBody(scope, stmt.Body, target, innerPath)
+				if err != nil {
+					return nil, err
+				}
+				applyTagDefaults(target, innerSet)
+			}
+
+			set[topIdx] = true
+		}
+	}
+
+	if err := vm.applyOverlay(path, rv, set); err != nil {
+		return nil, err
+	}
+
+	return set, nil
+}
+
+// decodeBlockElement decodes a single occurrence of a repeated block
+// (one element of a `,block,optional` slice or map field) into a fresh
+// value of elemType, which may itself be a struct or a pointer to one.
+func (vm *VM) decodeBlockElement(scope *Scope, stmt *ast.BlockStmt, elemType reflect.Type, path []string) (reflect.Value, error) {
+	elem := reflect.New(elemType).Elem()
+	target := elem
+	if elemType.Kind() == reflect.Ptr {
+		target.Set(reflect.New(elemType.Elem()))
+		target = target.Elem()
+	}
+
+	alloytags.CallSetToDefault(target.Addr().Interface())
+
+	innerSet, err := vm.decodeBody(scope, stmt.Body, target, path)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	applyTagDefaults(target, innerSet)
+
+	return elem, nil
+}
+
+// resolveField locates the destination for the given alloy name/kind
+// within rv, descending into anonymous fields with no alloy tag of their
+// own the same way builder.Body.AppendFrom flattens them when encoding.
+// A nil anonymous pointer is allocated before descending so its fields
+// can be set. topIdx is the index, within rv's own type, of the field the
+// match was found through (itself for a direct match, or the anonymous
+// field it was promoted from) - this is what decodeBody records in `set`.
+func resolveField(rv reflect.Value, name string, wantBlock bool) (reflect.Value, reflect.StructField, int, bool) {
+	wantKind := alloytags.KindAttr
+	if wantBlock {
+		wantKind = alloytags.KindBlock
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag, hasTag := alloytags.Parse(field)
+
+		if hasTag {
+			if tag.Name == name && tag.Kind == wantKind {
+				return rv.Field(i), field, i, true
+			}
+			continue
+		}
+
+		if !field.Anonymous {
+			continue
+		}
+
+		fv := rv.Field(i)
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				if fv.Type().Elem().Kind() != reflect.Struct || !fv.CanSet() {
+					continue
+				}
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() != reflect.Struct {
+			continue
+		}
+
+		if target, f, _, ok := resolveField(fv, name, wantBlock); ok {
+			return target, f, i, true
+		}
+	}
+
+	return reflect.Value{}, reflect.StructField{}, 0, false
+}
+
+// evalExpr evaluates a scalar expression into a value assignable to ft.
+// Only literal expressions are supported; any other expression
+// (identifiers, function calls, arithmetic) returns an error.
+func (vm *VM) evalExpr(scope *Scope, expr ast.Expr, ft reflect.Type) (reflect.Value, error) {
+	lit, ok := expr.(*ast.LiteralExpr)
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("unsupported expression %T", expr)
+	}
+
+	switch ft.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(lit.Value).Convert(ft), nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(lit.Value)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(b).Convert(ft), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(lit.Value, 10, 64)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out := reflect.New(ft).Elem()
+		out.SetInt(n)
+		return out, nil
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(lit.Value, 64)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out := reflect.New(ft).Elem()
+		out.SetFloat(n)
+		return out, nil
+	default:
+		return reflect.Value{}, fmt.Errorf("unsupported field kind %s", ft.Kind())
+	}
+}